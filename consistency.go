@@ -0,0 +1,193 @@
+package merkle
+
+import (
+	"bytes"
+	"hash"
+)
+
+// NewConsistencyTree produces a new Tree for building an RFC 6962-style
+// consistency proof.
+// https://www.rfc-editor.org/rfc/rfc6962#section-2.1.2
+//
+// priorSize is the number of leaves in an earlier tree, T1, built from a
+// prefix of the same sequence of strings. The caller must Add the
+// complete, extended sequence of strings (all of T2's leaves, in the same
+// order used to build T1) and then call ConsistencyProof.
+//
+// The resulting proof lets a verifier who knows only Root(T1), Root(T2),
+// priorSize, and the total number of leaves confirm that T1 is an
+// unchanged prefix of T2, without seeing any of the leaves themselves.
+//
+// Memory warning: unlike a plain Tree, a consistency Tree retains the
+// hash of every leaf added to it (O(n) space for n leaves), since
+// producing the proof requires recomputing the Merkle tree hash of
+// arbitrary sub-ranges of the sequence, not just the current right
+// spine. This is a deliberate departure from the rest of the package:
+// a plain HTree needs only O(log n) space because it never looks at
+// anything but that spine. A caller that also wants
+// the resumable, disk-backed streaming ingest described for a plain
+// HTree (see HTree.MarshalBinary) cannot get it from a consistency
+// tree; every leaf since T1 must be held in memory until
+// ConsistencyProof is called.
+
+func NewConsistencyTree(hasher hash.Hash, priorSize int) *Tree {
+	return &Tree{htree: newConsistencyHTree(hasher, priorSize)}
+}
+
+func newConsistencyHTree(hasher hash.Hash, priorSize int) *HTree {
+	return &HTree{hasher: hasher, consistency: &consistencyState{priorSize: priorSize}}
+}
+
+// ConsistencyProof returns the consistency proof for the prior tree size
+// given to NewConsistencyTree. It is an error to call Add after a call to
+// ConsistencyProof.
+func (m *Tree) ConsistencyProof() Proof {
+	return m.htree.ConsistencyProof()
+}
+
+// ConsistencyProof returns the consistency proof for the prior tree size
+// given to newConsistencyHTree. It is an error to call Add after a call
+// to ConsistencyProof.
+func (h *HTree) ConsistencyProof() Proof {
+	cs := h.consistency
+	if cs.priorSize == 0 || cs.priorSize == len(cs.leaves) {
+		// An empty old tree, or one identical in size to the new tree,
+		// needs no proof steps at all.
+		return Proof{}
+	}
+	return Proof{Steps: subProof(h.hasher, cs.leaves, cs.priorSize, true)}
+}
+
+// consistencyState accumulates the leaf hashes added to a tree created
+// with NewConsistencyTree or newConsistencyHTree. leaves grows to O(n)
+// for n leaves added; see the memory warning on NewConsistencyTree.
+type consistencyState struct {
+	priorSize int
+	leaves    [][]byte
+}
+
+// VerifyConsistency checks a consistency proof produced by
+// HTree.ConsistencyProof (or Tree.ConsistencyProof). It confirms that the
+// tree of oldSize leaves with root oldRoot is an unchanged prefix of the
+// tree of newSize leaves with root newRoot.
+func (p Proof) VerifyConsistency(hasher hash.Hash, oldRoot, newRoot []byte, oldSize, newSize int) bool {
+	switch {
+	case oldSize < 0 || newSize < oldSize:
+		return false
+	case oldSize == 0:
+		// An empty tree is consistent with any later tree; its root is
+		// always the hasher's trivial empty-input sum (see HTree.finish).
+		hasher.Reset()
+		return bytes.Equal(oldRoot, hasher.Sum(nil))
+	case oldSize == newSize:
+		return len(p.Steps) == 0 && bytes.Equal(oldRoot, newRoot)
+	}
+
+	idx := 0
+	oldHash, newHash, ok := subProofVerify(hasher, p.Steps, &idx, oldRoot, oldSize, newSize, true)
+	if !ok || idx != len(p.Steps) {
+		return false
+	}
+	return bytes.Equal(oldHash, oldRoot) && bytes.Equal(newHash, newRoot)
+}
+
+// subProof implements RFC 6962's SUBPROOF(m, D[n], b), producing the
+// consistency-proof steps for the sub-range of leaves given, in the
+// order a verifier must consume them.
+func subProof(hasher hash.Hash, leaves [][]byte, m int, b bool) []ProofStep {
+	n := len(leaves)
+	if m == n {
+		if b {
+			// This whole sub-range is exactly the old tree (or an exact
+			// prefix node of it); its hash is already known to the
+			// verifier, either as oldRoot itself or as a value derived
+			// earlier in the proof, so nothing more needs to be sent.
+			return nil
+		}
+		return []ProofStep{{H: mth(hasher, leaves)}}
+	}
+
+	k := largestPowerOfTwoLessThan(n)
+	if m <= k {
+		steps := subProof(hasher, leaves[:k], m, b)
+		return append(steps, ProofStep{H: mth(hasher, leaves[k:])})
+	}
+	steps := subProof(hasher, leaves[k:], m-k, false)
+	return append(steps, ProofStep{H: mth(hasher, leaves[:k])})
+}
+
+// subProofVerify mirrors subProof, replaying the same recursion using
+// only the sizes m (old) and n (new sub-range) plus the next unconsumed
+// proof steps, to reconstruct the hash each level of subProof contributed
+// to the old tree's root (oldHash) and to the new tree's root (newHash).
+func subProofVerify(hasher hash.Hash, steps []ProofStep, idx *int, oldRoot []byte, m, n int, b bool) (oldHash, newHash []byte, ok bool) {
+	if m == n {
+		if b {
+			return oldRoot, oldRoot, true
+		}
+		if *idx >= len(steps) {
+			return nil, nil, false
+		}
+		h := steps[*idx].H
+		*idx++
+		return h, h, true
+	}
+
+	k := largestPowerOfTwoLessThan(n)
+	if m <= k {
+		oldHash, left, ok := subProofVerify(hasher, steps, idx, oldRoot, m, k, b)
+		if !ok || *idx >= len(steps) {
+			return nil, nil, false
+		}
+		sib := steps[*idx].H
+		*idx++
+		combined := make([]byte, hasher.Size())
+		interiorHash(hasher, combined[:0], left, sib, nil, nil)
+		return oldHash, combined, true
+	}
+
+	oldRight, newRight, ok := subProofVerify(hasher, steps, idx, oldRoot, m-k, n-k, false)
+	if !ok || *idx >= len(steps) {
+		return nil, nil, false
+	}
+	sib := steps[*idx].H
+	*idx++
+
+	old := make([]byte, hasher.Size())
+	interiorHash(hasher, old[:0], sib, oldRight, nil, nil)
+
+	combined := make([]byte, hasher.Size())
+	interiorHash(hasher, combined[:0], sib, newRight, nil, nil)
+
+	return old, combined, true
+}
+
+// mth computes the RFC 6962 Merkle Tree Hash of a (sub-)sequence of leaf
+// hashes.
+func mth(hasher hash.Hash, leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		hasher.Reset()
+		return hasher.Sum(nil)
+	case 1:
+		out := make([]byte, len(leaves[0]))
+		copy(out, leaves[0])
+		return out
+	}
+	k := largestPowerOfTwoLessThan(len(leaves))
+	left := mth(hasher, leaves[:k])
+	right := mth(hasher, leaves[k:])
+	out := make([]byte, hasher.Size())
+	interiorHash(hasher, out[:0], left, right, nil, nil)
+	return out
+}
+
+// largestPowerOfTwoLessThan returns the largest power of two strictly
+// less than n. n must be at least 2.
+func largestPowerOfTwoLessThan(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
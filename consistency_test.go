@@ -0,0 +1,81 @@
+package merkle
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func TestConsistencyProof(t *testing.T) {
+	var all [][]byte
+	for i := 0; i < 20; i++ {
+		all = append(all, []byte{byte(i)})
+	}
+
+	rootOf := func(n int) []byte {
+		tree := NewTree(sha256.New())
+		for _, s := range all[:n] {
+			tree.Add(s)
+		}
+		return tree.Root()
+	}
+
+	for oldSize := 0; oldSize <= len(all); oldSize++ {
+		for newSize := oldSize; newSize <= len(all); newSize++ {
+			ctree := NewConsistencyTree(sha256.New(), oldSize)
+			for _, s := range all[:newSize] {
+				ctree.Add(s)
+			}
+			proof := ctree.ConsistencyProof()
+
+			oldRoot, newRoot := rootOf(oldSize), rootOf(newSize)
+
+			if !proof.VerifyConsistency(sha256.New(), oldRoot, newRoot, oldSize, newSize) {
+				t.Errorf("oldSize %d, newSize %d: valid proof failed to verify", oldSize, newSize)
+			}
+		}
+	}
+}
+
+func TestConsistencyProofRejectsTampering(t *testing.T) {
+	var all [][]byte
+	for i := 0; i < 10; i++ {
+		all = append(all, []byte{byte(i)})
+	}
+
+	ctree := NewConsistencyTree(sha256.New(), 3)
+	for _, s := range all {
+		ctree.Add(s)
+	}
+	proof := ctree.ConsistencyProof()
+
+	oldTree := NewTree(sha256.New())
+	for _, s := range all[:3] {
+		oldTree.Add(s)
+	}
+	oldRoot := oldTree.Root()
+
+	newTree := NewTree(sha256.New())
+	for _, s := range all {
+		newTree.Add(s)
+	}
+	newRoot := newTree.Root()
+
+	if !proof.VerifyConsistency(sha256.New(), oldRoot, newRoot, 3, len(all)) {
+		t.Fatal("valid proof failed to verify")
+	}
+
+	wrongRoot := append([]byte{}, oldRoot...)
+	wrongRoot[0] ^= 0xff
+	if proof.VerifyConsistency(sha256.New(), wrongRoot, newRoot, 3, len(all)) {
+		t.Error("proof verified against a tampered old root")
+	}
+
+	wrongSizeTree := NewTree(sha256.New())
+	for _, s := range all[:len(all)-1] {
+		wrongSizeTree.Add(s)
+	}
+	wrongSizeRoot := wrongSizeTree.Root()
+	if proof.VerifyConsistency(sha256.New(), oldRoot, wrongSizeRoot, 3, len(all)-1) {
+		t.Error("proof verified against the wrong new size")
+	}
+}
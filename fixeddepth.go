@@ -0,0 +1,172 @@
+package merkle
+
+import (
+	"bytes"
+	"hash"
+)
+
+// NewFixedDepthTree produces a new Tree whose root is always the root
+// of a complete binary tree of exactly 2^depth leaves. A leaf that is
+// never set via AddAt is treated as the precomputed hash of an
+// all-zero subtree of the appropriate height, so two fixed-depth trees
+// of the same depth with the same leaves set at the same indices
+// always produce the same root, regardless of how many leaves are
+// missing or in what order AddAt was called.
+//
+// This is the fixed-generalized-index Merkleization scheme used by
+// SSZ/beacon-chain commitments, where a leaf's position is itself
+// semantically meaningful. Unlike a plain Tree, elements are added with
+// AddAt rather than Add, and AddAt may be called in any order or left
+// sparse.
+func NewFixedDepthTree(hasher hash.Hash, depth uint8) *Tree {
+	return &Tree{htree: newFixedDepthHTree(hasher, depth, nil)}
+}
+
+// NewFixedDepthProofTree produces a new fixed-depth Tree (see
+// NewFixedDepthTree) that can compactly prove a given string is one of
+// its leaves. After adding elements with AddAt, call Proof to get the
+// proof. The proof always has exactly depth steps, padded with
+// zero-subtree hashes where necessary, so a verifier does not need to
+// know how many leaves were actually set.
+func NewFixedDepthProofTree(hasher hash.Hash, depth uint8, ref []byte) *Tree {
+	return &Tree{htree: newFixedDepthHTree(hasher, depth, ref)}
+}
+
+func newFixedDepthHTree(hasher hash.Hash, depth uint8, ref []byte) *HTree {
+	fd := &fixedDepthState{
+		depth:      depth,
+		zeroHashes: zeroHashes(hasher, depth),
+		leaves:     make(map[uint64][]byte),
+	}
+	if ref != nil {
+		fd.ref = LeafHash(hasher, nil, ref)
+	}
+	return &HTree{hasher: hasher, fixedDepth: fd}
+}
+
+// fixedDepthState accumulates the sparse leaves of a tree created with
+// NewFixedDepthTree or NewFixedDepthProofTree.
+type fixedDepthState struct {
+	depth      uint8
+	zeroHashes [][]byte // zeroHashes[i] is the hash of an all-zero subtree of height i
+	leaves     map[uint64][]byte
+
+	ref      []byte
+	refIndex uint64
+}
+
+// MaxLeaves returns the number of leaf positions in m's fixed-depth
+// tree: 2^depth. For a depth of 64 or more, 2^depth does not fit in a
+// uint64; MaxLeaves then returns 0, and AddAt rejects every index.
+func (m *Tree) MaxLeaves() uint64 {
+	return m.htree.fixedDepth.maxLeaves()
+}
+
+func (fd *fixedDepthState) maxLeaves() uint64 {
+	return uint64(1) << fd.depth
+}
+
+// AddAt sets the leaf at index to str in m's fixed-depth tree,
+// replacing any value previously set at the same index. The caller may
+// reuse the space in str. It is an error to call AddAt with an index
+// that is not less than MaxLeaves(), or after a call to Root or Proof.
+func (m *Tree) AddAt(index uint64, str []byte) {
+	m.htree.AddAt(index, LeafHash(m.htree.hasher, nil, str))
+}
+
+// AddAt sets the leaf hash at index to item in h's fixed-depth tree,
+// replacing any value previously set at the same index. The caller
+// must not reuse the space in item. It is an error to call AddAt with
+// an index that is not less than MaxLeaves(), or after a call to Root
+// or Proof.
+func (h *HTree) AddAt(index uint64, item []byte) {
+	fd := h.fixedDepth
+	if index >= fd.maxLeaves() {
+		panic("merkle: index out of range for fixed-depth tree")
+	}
+	if fd.ref != nil && bytes.Equal(fd.ref, item) {
+		fd.refIndex = index
+	}
+	fd.leaves[index] = item
+}
+
+// hasRef reports whether fd's reference leaf is still present at
+// refIndex. Unlike refIndex itself, this is not latched: if the leaf
+// at refIndex is later overwritten with a different value via AddAt,
+// hasRef reports false again.
+func (fd *fixedDepthState) hasRef() bool {
+	return fd.ref != nil && bytes.Equal(fd.leaves[fd.refIndex], fd.ref)
+}
+
+// root computes the root of fd's fixed-depth tree by combining levels
+// bottom-up, starting from the sparse leaves and consulting
+// zeroHashes for any index with no explicit child at that level. Since
+// each level's map holds only the ancestors of actually-set leaves,
+// this costs O(len(leaves) * depth), not O(2^depth).
+func (fd *fixedDepthState) root(hasher hash.Hash) []byte {
+	level := fd.leaves
+	for d := uint8(0); d < fd.depth; d++ {
+		level = fixedDepthNextLevel(hasher, level, fd.zeroHashes[d])
+	}
+	return fixedDepthNodeAt(level, fd.zeroHashes[fd.depth], 0)
+}
+
+// proof computes the fixed-depth inclusion proof for the leaf at
+// fd.refIndex, recording the sibling hash at each level on its way up
+// to the root. The result always has exactly fd.depth steps.
+func (fd *fixedDepthState) proof(hasher hash.Hash) Proof {
+	if !fd.hasRef() {
+		return Proof{}
+	}
+
+	steps := make([]ProofStep, fd.depth)
+	level, idx := fd.leaves, fd.refIndex
+	for d := uint8(0); d < fd.depth; d++ {
+		sibling := fixedDepthNodeAt(level, fd.zeroHashes[d], idx^1)
+		steps[d] = ProofStep{H: sibling, Left: idx%2 == 1}
+
+		level = fixedDepthNextLevel(hasher, level, fd.zeroHashes[d])
+		idx /= 2
+	}
+	return Proof{Steps: steps}
+}
+
+// fixedDepthNextLevel combines a level of a fixed-depth tree (indexed
+// by position within that level) into the level above it, using zero
+// for the hash of any index with no entry in level.
+func fixedDepthNextLevel(hasher hash.Hash, level map[uint64][]byte, zero []byte) map[uint64][]byte {
+	next := make(map[uint64][]byte, (len(level)+1)/2)
+	for idx := range level {
+		parent := idx / 2
+		if _, done := next[parent]; done {
+			continue
+		}
+		left := fixedDepthNodeAt(level, zero, parent*2)
+		right := fixedDepthNodeAt(level, zero, parent*2+1)
+		out := make([]byte, hasher.Size())
+		interiorHash(hasher, out[:0], left, right, nil, nil)
+		next[parent] = out
+	}
+	return next
+}
+
+func fixedDepthNodeAt(level map[uint64][]byte, zero []byte, idx uint64) []byte {
+	if h, ok := level[idx]; ok {
+		return h
+	}
+	return zero
+}
+
+// zeroHashes precomputes, for a fixed-depth tree of the given depth,
+// the hash of an all-zero subtree at every height from 0 (a single
+// empty leaf) up to depth (the whole tree).
+func zeroHashes(hasher hash.Hash, depth uint8) [][]byte {
+	z := make([][]byte, depth+1)
+	z[0] = LeafHash(hasher, nil, nil)
+	for i := uint8(1); i <= depth; i++ {
+		out := make([]byte, hasher.Size())
+		interiorHash(hasher, out[:0], z[i-1], z[i-1], nil, nil)
+		z[i] = out
+	}
+	return z
+}
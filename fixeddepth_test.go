@@ -0,0 +1,107 @@
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestFixedDepthTreeOrderAndSparsityIndependent(t *testing.T) {
+	const depth = 4
+
+	leaves := map[uint64][]byte{
+		1:  []byte("one"),
+		3:  []byte("three"),
+		12: []byte("twelve"),
+	}
+
+	build := func(order []uint64) []byte {
+		tree := NewFixedDepthTree(sha256.New(), depth)
+		for _, idx := range order {
+			tree.AddAt(idx, leaves[idx])
+		}
+		return tree.Root()
+	}
+
+	want := build([]uint64{1, 3, 12})
+	got := build([]uint64{12, 1, 3})
+	if !bytes.Equal(got, want) {
+		t.Error("root depends on the order AddAt was called in")
+	}
+}
+
+func TestFixedDepthTreeEmptyEqualsZeroHash(t *testing.T) {
+	const depth = 3
+
+	empty := NewFixedDepthTree(sha256.New(), depth).Root()
+	want := zeroHashes(sha256.New(), depth)[depth]
+	if !bytes.Equal(empty, want) {
+		t.Error("root of an empty fixed-depth tree does not equal the top zero hash")
+	}
+}
+
+func TestFixedDepthTreeMaxLeaves(t *testing.T) {
+	tree := NewFixedDepthTree(sha256.New(), 5)
+	if got, want := tree.MaxLeaves(), uint64(32); got != want {
+		t.Errorf("MaxLeaves() = %d, want %d", got, want)
+	}
+}
+
+func TestFixedDepthProof(t *testing.T) {
+	const depth = 4
+
+	for _, present := range [][]uint64{
+		{0},
+		{15},
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+		{2, 5, 9},
+	} {
+		tree := NewFixedDepthTree(sha256.New(), depth)
+		for _, idx := range present {
+			tree.AddAt(idx, []byte{byte(idx)})
+		}
+		root := tree.Root()
+
+		for _, idx := range present {
+			ptree := NewFixedDepthProofTree(sha256.New(), depth, []byte{byte(idx)})
+			for _, i := range present {
+				ptree.AddAt(i, []byte{byte(i)})
+			}
+			proof := ptree.Proof()
+
+			if len(proof.Steps) != depth {
+				t.Errorf("present=%v idx=%d: proof has %d steps, want %d", present, idx, len(proof.Steps), depth)
+			}
+			if got := proof.Hash(sha256.New(), []byte{byte(idx)}); !bytes.Equal(got, root) {
+				t.Errorf("present=%v idx=%d: proof hash does not match root", present, idx)
+			}
+		}
+	}
+}
+
+func TestFixedDepthTreeAddAtOverwrites(t *testing.T) {
+	tree := NewFixedDepthTree(sha256.New(), 2)
+	tree.AddAt(0, []byte("old"))
+	tree.AddAt(0, []byte("new"))
+
+	want := NewFixedDepthTree(sha256.New(), 2)
+	want.AddAt(0, []byte("new"))
+
+	if !bytes.Equal(tree.Root(), want.Root()) {
+		t.Error("AddAt did not overwrite the previous value at the same index")
+	}
+}
+
+func TestFixedDepthProofStaleAfterOverwrite(t *testing.T) {
+	const depth = 2
+	ref := []byte("ref")
+
+	tree := NewFixedDepthProofTree(sha256.New(), depth, ref)
+	tree.AddAt(0, ref)
+	tree.AddAt(0, []byte("other"))
+
+	proof := tree.Proof()
+	if len(proof.Steps) != 0 {
+		t.Errorf("proof has %d steps, want 0 once the reference leaf is overwritten", len(proof.Steps))
+	}
+}
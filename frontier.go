@@ -1,6 +1,9 @@
 package merkle
 
-import "hash"
+import (
+	"bytes"
+	"hash"
+)
 
 type tier interface {
 	get(byte) tier
@@ -84,11 +87,87 @@ func (f *Frontier) MerkleProofTree(hasher hash.Hash, ref []byte) *Tree {
 	return m
 }
 
+// NonMembershipProof finds the shortest leaf of f's Merkle tree (see
+// MerkleTree) that relates s by prefix -- either a genuine prefix of s,
+// or (see leafPrefix) a one-byte extension of s itself -- meaning every
+// string sharing that relationship, including s, is excluded. It
+// returns that leaf along with a Merkle inclusion proof of it. A third
+// party who knows only the frontier's root hash, s, and the result can
+// check it with VerifyNonMembership, without needing f itself.
+//
+// The final return value is false if s has no such leaf, which happens
+// when s is itself a member of f's complement set: its path through f's
+// trie ends at a tier that still has children, so no prefix of s is a
+// leaf. When f is empty, every string shares the empty prefix, so this
+// can never happen.
+func (f *Frontier) NonMembershipProof(hasher hash.Hash, s []byte) ([]byte, Proof, bool) {
+	prefix, ok := f.leafPrefix(s)
+	if !ok {
+		return nil, Proof{}, false
+	}
+	tree := f.MerkleProofTree(hasher, prefix)
+	return prefix, tree.Proof(), true
+}
+
+// VerifyNonMembership checks a proof produced by
+// Frontier.NonMembershipProof. It confirms that prefix is a prefix of s,
+// or s is a prefix of prefix (this second case arises only when s is
+// itself a string previously passed to Exclude; see leafPrefix), and
+// that proof proves prefix's inclusion in the frontier tree with the
+// given root.
+func VerifyNonMembership(hasher hash.Hash, root, s, prefix []byte, proof Proof) bool {
+	if !bytes.HasPrefix(s, prefix) && !bytes.HasPrefix(prefix, s) {
+		return false
+	}
+	return bytes.Equal(proof.Hash(hasher, prefix), root)
+}
+
+// leafPrefix finds the shortest prefix of s that walkHelper would emit
+// as a leaf: the point along s's path through f's trie where a tier has
+// no child for the next byte of s. This can consume one byte of s past
+// a tier added by Exclude, since Exclude leaves a zerotier in place to
+// mark a fully excluded subtree, and every one of that zerotier's own
+// children is in turn an implicit leaf.
+//
+// s may also exactly match the path of a string previously passed to
+// Exclude, landing on its zerotier with no bytes of s left to consume.
+// s itself is never a Merkle leaf in that case -- every leaf under that
+// zerotier is one byte longer than s -- so leafPrefix instead returns
+// one such leaf, s with a single zero byte appended. That leaf could
+// only exist because Exclude was called on exactly s, so proving it
+// excluded is enough to prove s is too; VerifyNonMembership accepts a
+// returned prefix that is either a prefix of s or (as here) the other
+// way around.
+func (f *Frontier) leafPrefix(s []byte) ([]byte, bool) {
+	if f.top == nil || f.top.empty() {
+		return nil, true
+	}
+	tier := f.top
+	for i, b := range s {
+		if tier == nil {
+			return nil, false
+		}
+		subtier := tier.get(b)
+		if subtier == nil {
+			return append([]byte{}, s[:i+1]...), true
+		}
+		tier = subtier
+	}
+	if tier != nil && tier.empty() {
+		return append(append([]byte{}, s...), 0), true
+	}
+	return nil, false
+}
+
 // Walk performs an in-order depth-first traversal of f,
 // calling a callback on each string.
 // The callback must make its own copy of the string if needed;
 // Walk reuses the space on each callback call.
 func (f *Frontier) Walk(fn func(str []byte)) {
+	if f.top == nil {
+		fn(nil)
+		return
+	}
 	walkHelper(f.top, fn, nil)
 }
 
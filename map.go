@@ -0,0 +1,129 @@
+package merkle
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash"
+	"sort"
+)
+
+// MapTree is an authenticated key/value dictionary built on top of
+// HTree. Callers Set any number of key/value pairs, in any order
+// (including repeated Sets of the same key, which overwrite its
+// value), then call Root to get a Merkle root that depends only on the
+// final set of pairs, not on the order they were set in.
+//
+// Compared to a plain Tree, whose root depends on the sequence its
+// strings were added in, a MapTree gives this package a proper
+// authenticated map: Prove and Proof.VerifyMap let a client demonstrate,
+// and a verifier confirm, that a given key is associated with a given
+// value in the map with a given root.
+type MapTree struct {
+	hasher  hash.Hash
+	entries map[string]mapEntry
+}
+
+type mapEntry struct {
+	key, value []byte
+}
+
+// NewMapTree produces a new MapTree.
+func NewMapTree(hasher hash.Hash) *MapTree {
+	return &MapTree{hasher: hasher, entries: make(map[string]mapEntry)}
+}
+
+// Set associates value with key in m, replacing any value previously
+// set for the same key. The caller may reuse the space in key and
+// value.
+func (m *MapTree) Set(key, value []byte) {
+	m.entries[string(key)] = mapEntry{
+		key:   append([]byte{}, key...),
+		value: append([]byte{}, value...),
+	}
+}
+
+// Root returns the Merkle root of m's key/value pairs.
+func (m *MapTree) Root() []byte {
+	tree := NewHTree(m.hasher)
+	for _, e := range m.sortedEntries() {
+		tree.Add(mapLeafHash(m.hasher, nil, e.key, e.value))
+	}
+	return tree.Root()
+}
+
+// Prove returns the value associated with key in m, along with a Merkle
+// proof of that association, suitable for a caller to pass (with key
+// and value) to Proof.VerifyMap. The final return value is false, and
+// the rest of the result is the zero value, if key is not present in m.
+func (m *MapTree) Prove(key []byte) ([]byte, Proof, bool) {
+	entry, ok := m.entries[string(key)]
+	if !ok {
+		return nil, Proof{}, false
+	}
+
+	ref := mapLeafHash(m.hasher, nil, entry.key, entry.value)
+	tree := NewProofHTree(m.hasher, ref)
+	for _, e := range m.sortedEntries() {
+		tree.Add(mapLeafHash(m.hasher, nil, e.key, e.value))
+	}
+	return entry.value, tree.Proof(), true
+}
+
+// sortedEntries returns m's entries ordered by the hash of their key,
+// so that Root and Prove produce the same leaf sequence regardless of
+// the order pairs were set in.
+func (m *MapTree) sortedEntries() []mapEntry {
+	out := make([]mapEntry, 0, len(m.entries))
+	keyHashes := make(map[string][]byte, len(m.entries))
+	for k, e := range m.entries {
+		out = append(out, e)
+		keyHashes[k] = mapKeyHash(m.hasher, e.key)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return bytes.Compare(keyHashes[string(out[i].key)], keyHashes[string(out[j].key)]) < 0
+	})
+	return out
+}
+
+// VerifyMap checks a Merkle proof produced by MapTree.Prove. It
+// confirms that key is associated with value in the map whose root is
+// root.
+func (p Proof) VerifyMap(hasher hash.Hash, root, key, value []byte) bool {
+	leaf := mapLeafHash(hasher, nil, key, value)
+	return bytes.Equal(p.Hash(hasher, leaf), root)
+}
+
+// mapKeyHash hashes a MapTree key alone, for ordering entries
+// independently of insertion order.
+func mapKeyHash(h hash.Hash, key []byte) []byte {
+	h.Reset()
+
+	// Domain separator, distinct from the leaf (0x00) and interior
+	// (0x01) tags used elsewhere in this package.
+	h.Write([]byte{2})
+
+	h.Write(key)
+	return h.Sum(nil)
+}
+
+// mapLeafHash computes the domain-separated hash of a single MapTree
+// key/value pair: H(0x02 || len(key) || key || len(value) || value).
+// The length prefixes keep differently split key/value boundaries from
+// colliding; the leading 0x02 is a domain separator distinct from the
+// 0x00 and 0x01 tags used elsewhere in this package, so a map leaf can
+// never collide with a Tree leaf or an interior node hash.
+func mapLeafHash(h hash.Hash, out, key, value []byte) []byte {
+	h.Reset()
+	h.Write([]byte{2})
+
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(key)))
+	h.Write(lenBuf[:])
+	h.Write(key)
+
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(value)))
+	h.Write(lenBuf[:])
+	h.Write(value)
+
+	return h.Sum(out)
+}
@@ -0,0 +1,84 @@
+package merkle
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+func TestMapTreeOrderIndependent(t *testing.T) {
+	pairs := [][2]string{
+		{"alice", "1"},
+		{"bob", "2"},
+		{"carol", "3"},
+		{"dave", "4"},
+	}
+
+	var orders [][]int
+	orders = append(orders, []int{0, 1, 2, 3})
+	orders = append(orders, []int{3, 2, 1, 0})
+	orders = append(orders, []int{2, 0, 3, 1})
+
+	var roots [][]byte
+	for _, order := range orders {
+		m := NewMapTree(sha256.New())
+		for _, i := range order {
+			m.Set([]byte(pairs[i][0]), []byte(pairs[i][1]))
+		}
+		roots = append(roots, m.Root())
+	}
+
+	for i := 1; i < len(roots); i++ {
+		if string(roots[i]) != string(roots[0]) {
+			t.Errorf("order %v produced a different root than order %v", orders[i], orders[0])
+		}
+	}
+}
+
+func TestMapTreeSetOverwrites(t *testing.T) {
+	m := NewMapTree(sha256.New())
+	m.Set([]byte("key"), []byte("old"))
+	m.Set([]byte("key"), []byte("new"))
+
+	value, proof, ok := m.Prove([]byte("key"))
+	if !ok {
+		t.Fatal("Prove reported key missing")
+	}
+	if string(value) != "new" {
+		t.Errorf("got value %q, want %q", value, "new")
+	}
+
+	root := m.Root()
+	if !proof.VerifyMap(sha256.New(), root, []byte("key"), value) {
+		t.Error("valid map proof failed to verify")
+	}
+}
+
+func TestMapTreeProve(t *testing.T) {
+	m := NewMapTree(sha256.New())
+	var keys [][]byte
+	for i := 0; i < 20; i++ {
+		key := []byte(fmt.Sprintf("key%d", i))
+		value := []byte(fmt.Sprintf("value%d", i))
+		m.Set(key, value)
+		keys = append(keys, key)
+	}
+	root := m.Root()
+
+	for _, key := range keys {
+		value, proof, ok := m.Prove(key)
+		if !ok {
+			t.Fatalf("Prove reported %q missing", key)
+		}
+		if !proof.VerifyMap(sha256.New(), root, key, value) {
+			t.Errorf("valid map proof for %q failed to verify", key)
+		}
+		if proof.VerifyMap(sha256.New(), root, key, []byte("wrong")) {
+			t.Errorf("map proof for %q verified against the wrong value", key)
+		}
+	}
+
+	if _, _, ok := m.Prove([]byte("missing")); ok {
+		t.Error("Prove reported a missing key present")
+	}
+}
@@ -79,6 +79,25 @@ type HTree struct {
 	ref    *[]byte
 	proof  *Proof
 	hasher hash.Hash
+
+	// consistency is non-nil for a tree created with newConsistencyHTree.
+	// It accumulates every leaf hash added so a consistency proof can be
+	// produced once the full, extended sequence has been seen. This
+	// costs O(n) space instead of the O(log n) the hashes field above
+	// needs on its own; see the memory warning on NewConsistencyTree.
+	consistency *consistencyState
+
+	// multiproof is non-nil for a tree created with newMultiProofHTree. It
+	// accumulates every leaf hash added so a multi-leaf proof can be
+	// produced once all leaves have been seen. This costs O(n) space
+	// instead of the O(log n) the hashes field above needs on its own;
+	// see the memory warning on NewMultiProofTree.
+	multiproof *multiProofState
+
+	// fixedDepth is non-nil for a tree created with newFixedDepthHTree.
+	// Such a tree ignores hashes, root, ref, and proof above, and stores
+	// its leaves sparsely by index instead; see AddAt.
+	fixedDepth *fixedDepthState
 }
 
 // NewHTree produces a new HTree.
@@ -104,7 +123,27 @@ func newHTree(hasher hash.Hash, ref []byte) *HTree {
 // Add adds a leaf hash to the sequence in h.
 // The caller must not reuse the space in item.
 // It is an error to call Add after a call to Root or Proof.
+// It is an error to call Add on a tree created with newFixedDepthHTree;
+// use AddAt instead.
 func (h *HTree) Add(item []byte) {
+	if h.fixedDepth != nil {
+		panic("merkle: use AddAt, not Add, on a fixed-depth tree")
+	}
+	if h.consistency != nil || h.multiproof != nil {
+		// A consistency or multi-leaf proof must be able to recompute the
+		// Merkle tree hash of arbitrary sub-ranges of the leaf sequence,
+		// not just the current right spine, so the full sequence has to
+		// be kept.
+		leaf := make([]byte, len(item))
+		copy(leaf, item)
+		switch {
+		case h.consistency != nil:
+			h.consistency.leaves = append(h.consistency.leaves, leaf)
+		case h.multiproof != nil:
+			h.multiproof.leaves = append(h.multiproof.leaves, leaf)
+		}
+	}
+
 	// Find the lowest height in hashes where this hash fits.
 	// For each level where it does not fit,
 	// compute a combined hash, empty that level,
@@ -158,6 +197,9 @@ func (h *HTree) finish() {
 // for the sequence of leaf hashes that have been added to h with Add.
 // It is an error to call Add after a call to Root.
 func (h *HTree) Root() []byte {
+	if h.fixedDepth != nil {
+		return h.fixedDepth.root(h.hasher)
+	}
 	h.finish()
 	return *h.root
 }
@@ -165,6 +207,9 @@ func (h *HTree) Root() []byte {
 // Proof returns the Merkle inclusion proof for the reference hash given to NewProofHTree.
 // It is an error to call Add after a call to Proof.
 func (h *HTree) Proof() Proof {
+	if h.fixedDepth != nil {
+		return h.fixedDepth.proof(h.hasher)
+	}
 	h.finish()
 	return *h.proof
 }
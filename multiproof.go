@@ -0,0 +1,230 @@
+package merkle
+
+import (
+	"bytes"
+	"hash"
+	"sort"
+)
+
+// NewMultiProofTree produces a new Tree that can compactly prove that
+// every string in refs is in it, in a single combined proof. After
+// adding elements to the tree, call MultiProof to get the proof.
+//
+// Compared to producing one Proof per ref, a MultiProof deduplicates any
+// authentication-path hash shared by more than one ref, which can
+// meaningfully shrink proof size when auditing many leaves of a large
+// tree (for example, many chunks of a large file).
+//
+// Memory warning: unlike a plain Tree, a multi-proof Tree retains the
+// hash of every leaf added to it (O(n) space for n leaves), since
+// producing the proof requires recomputing the Merkle tree hash of
+// arbitrary sub-ranges of the sequence, not just the current right
+// spine. This is the same tradeoff NewConsistencyTree makes, and for
+// the same reason: a plain HTree's O(log n), resumable, disk-backed
+// streaming ingest (see HTree.MarshalBinary) is not available here.
+func NewMultiProofTree(hasher hash.Hash, refs [][]byte) *Tree {
+	hashedRefs := make([][]byte, len(refs))
+	for i, ref := range refs {
+		hashedRefs[i] = LeafHash(hasher, nil, ref)
+	}
+	return &Tree{htree: newMultiProofHTree(hasher, hashedRefs)}
+}
+
+func newMultiProofHTree(hasher hash.Hash, refs [][]byte) *HTree {
+	return &HTree{hasher: hasher, multiproof: &multiProofState{refs: refs}}
+}
+
+// MultiProof returns the multi-leaf proof for the reference strings
+// given to NewMultiProofTree. It is an error to call Add after a call to
+// MultiProof.
+func (m *Tree) MultiProof() MultiProof {
+	return m.htree.MultiProof()
+}
+
+// MultiProof returns the multi-leaf proof for the reference hashes given
+// to newMultiProofHTree. It is an error to call Add after a call to
+// MultiProof.
+func (h *HTree) MultiProof() MultiProof {
+	mp := h.multiproof
+
+	var indices []int
+	for _, ref := range mp.refs {
+		for i, leaf := range mp.leaves {
+			if bytes.Equal(leaf, ref) {
+				indices = append(indices, i)
+				break
+			}
+		}
+	}
+	sort.Ints(indices)
+	indices = dedupInts(indices)
+
+	helpers := multiProofHelpers(h.hasher, mp.leaves, 0, len(mp.leaves), indices)
+
+	return MultiProof{Size: len(mp.leaves), Indices: indices, Helpers: helpers}
+}
+
+// multiProofState accumulates the leaf hashes and requested reference
+// hashes of a tree created with NewMultiProofTree or newMultiProofHTree.
+// leaves grows to O(n) for n leaves added; see the memory warning on
+// NewMultiProofTree.
+type multiProofState struct {
+	refs   [][]byte
+	leaves [][]byte
+}
+
+// MultiProof is a compact Merkle proof that a set of leaves, at a set of
+// known positions, is present in a tree of a known size.
+type MultiProof struct {
+	// Size is the total number of leaves in the tree the proof was
+	// produced from.
+	Size int
+
+	// Indices is the sorted list of leaf positions being proven.
+	Indices []int
+
+	// Helpers is the deduplicated list of authentication-path hashes
+	// needed to recompute the root, in the order Verify must consume
+	// them.
+	Helpers [][]byte
+}
+
+// Verify checks a multi-leaf proof. refs must be given in the same order
+// as p.Indices: refs[i] is the leaf at position p.Indices[i].
+func (p MultiProof) Verify(hasher hash.Hash, refs [][]byte, root []byte) bool {
+	if len(refs) == 0 || len(refs) != len(p.Indices) {
+		return false
+	}
+
+	leafHashes := make(map[int][]byte, len(refs))
+	for i, idx := range p.Indices {
+		leafHashes[idx] = LeafHash(hasher, nil, refs[i])
+	}
+
+	helperIdx := 0
+	got, ok := verifyMultiProofRange(hasher, p.Helpers, &helperIdx, leafHashes, p.Indices, 0, p.Size)
+	if !ok || helperIdx != len(p.Helpers) {
+		return false
+	}
+	return bytes.Equal(got, root)
+}
+
+// multiProofHelpers implements the generation side of a MultiProof,
+// recursing over the same largest-power-of-two split used by mth and
+// subProof. It returns the authentication-path hashes, in the order
+// Verify must consume them, needed to recompute the root given only the
+// leaves at the positions in indices.
+func multiProofHelpers(hasher hash.Hash, leaves [][]byte, lo, hi int, indices []int) [][]byte {
+	if hi <= lo {
+		// An empty range (only reachable when the tree has zero leaves)
+		// needs no helper hashes; its hash is the hasher's trivial
+		// empty-input sum, which mth and verifyMultiProofRange agree on
+		// without being told.
+		return nil
+	}
+	if hi-lo == 1 {
+		return nil
+	}
+
+	k := largestPowerOfTwoLessThan(hi - lo)
+	mid := lo + k
+	leftNeeded := anyIndexInRange(indices, lo, mid)
+	rightNeeded := anyIndexInRange(indices, mid, hi)
+
+	switch {
+	case leftNeeded && rightNeeded:
+		left := multiProofHelpers(hasher, leaves, lo, mid, indices)
+		right := multiProofHelpers(hasher, leaves, mid, hi, indices)
+		return append(left, right...)
+	case leftNeeded:
+		helpers := multiProofHelpers(hasher, leaves, lo, mid, indices)
+		return append(helpers, mth(hasher, leaves[mid:hi]))
+	default:
+		helpers := multiProofHelpers(hasher, leaves, mid, hi, indices)
+		return append(helpers, mth(hasher, leaves[lo:mid]))
+	}
+}
+
+// verifyMultiProofRange mirrors multiProofHelpers, replaying the same
+// recursion to recompute the hash of leaves[lo:hi] from the known leaf
+// hashes and the next unconsumed helper hashes.
+func verifyMultiProofRange(hasher hash.Hash, helpers [][]byte, idx *int, leafHashes map[int][]byte, indices []int, lo, hi int) ([]byte, bool) {
+	if hi <= lo {
+		hasher.Reset()
+		return hasher.Sum(nil), true
+	}
+	if hi-lo == 1 {
+		if lh, ok := leafHashes[lo]; ok {
+			return lh, true
+		}
+		if *idx >= len(helpers) {
+			return nil, false
+		}
+		h := helpers[*idx]
+		*idx++
+		return h, true
+	}
+
+	k := largestPowerOfTwoLessThan(hi - lo)
+	mid := lo + k
+	leftNeeded := anyIndexInRange(indices, lo, mid)
+	rightNeeded := anyIndexInRange(indices, mid, hi)
+
+	var left, right []byte
+	var ok bool
+
+	switch {
+	case leftNeeded && rightNeeded:
+		if left, ok = verifyMultiProofRange(hasher, helpers, idx, leafHashes, indices, lo, mid); !ok {
+			return nil, false
+		}
+		if right, ok = verifyMultiProofRange(hasher, helpers, idx, leafHashes, indices, mid, hi); !ok {
+			return nil, false
+		}
+	case leftNeeded:
+		if left, ok = verifyMultiProofRange(hasher, helpers, idx, leafHashes, indices, lo, mid); !ok {
+			return nil, false
+		}
+		if *idx >= len(helpers) {
+			return nil, false
+		}
+		right = helpers[*idx]
+		*idx++
+	case rightNeeded:
+		if right, ok = verifyMultiProofRange(hasher, helpers, idx, leafHashes, indices, mid, hi); !ok {
+			return nil, false
+		}
+		if *idx >= len(helpers) {
+			return nil, false
+		}
+		left = helpers[*idx]
+		*idx++
+	default:
+		return nil, false
+	}
+
+	out := make([]byte, hasher.Size())
+	interiorHash(hasher, out[:0], left, right, nil, nil)
+	return out, true
+}
+
+// anyIndexInRange tells whether the sorted slice indices contains a
+// value in [lo, hi).
+func anyIndexInRange(indices []int, lo, hi int) bool {
+	i := sort.SearchInts(indices, lo)
+	return i < len(indices) && indices[i] < hi
+}
+
+// dedupInts removes adjacent duplicates from a sorted slice of ints.
+func dedupInts(sorted []int) []int {
+	if len(sorted) == 0 {
+		return sorted
+	}
+	out := sorted[:1]
+	for _, v := range sorted[1:] {
+		if v != out[len(out)-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
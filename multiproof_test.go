@@ -0,0 +1,118 @@
+package merkle
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func TestMultiProof(t *testing.T) {
+	var all [][]byte
+	for i := 0; i < 20; i++ {
+		all = append(all, []byte{byte(i)})
+	}
+
+	for n := 1; n <= len(all); n++ {
+		leaves := all[:n]
+
+		tree := NewTree(sha256.New())
+		for _, s := range leaves {
+			tree.Add(s)
+		}
+		root := tree.Root()
+
+		for _, indices := range [][]int{
+			{0},
+			{n - 1},
+			{0, n - 1},
+			evenIndices(n),
+			allIndices(n),
+		} {
+			indices = dedupInts(indices)
+			if len(indices) == 0 {
+				continue
+			}
+
+			var refs [][]byte
+			for _, idx := range indices {
+				refs = append(refs, leaves[idx])
+			}
+
+			mtree := NewMultiProofTree(sha256.New(), refs)
+			for _, s := range leaves {
+				mtree.Add(s)
+			}
+			proof := mtree.MultiProof()
+
+			if !proof.Verify(sha256.New(), refs, root) {
+				t.Errorf("n=%d indices=%v: valid multi-proof failed to verify", n, indices)
+			}
+		}
+	}
+}
+
+func TestMultiProofRejectsTampering(t *testing.T) {
+	var all [][]byte
+	for i := 0; i < 10; i++ {
+		all = append(all, []byte{byte(i)})
+	}
+
+	tree := NewTree(sha256.New())
+	for _, s := range all {
+		tree.Add(s)
+	}
+	root := tree.Root()
+
+	refs := [][]byte{all[1], all[4], all[7]}
+	mtree := NewMultiProofTree(sha256.New(), refs)
+	for _, s := range all {
+		mtree.Add(s)
+	}
+	proof := mtree.MultiProof()
+
+	if !proof.Verify(sha256.New(), refs, root) {
+		t.Fatal("valid multi-proof failed to verify")
+	}
+
+	wrongRoot := append([]byte{}, root...)
+	wrongRoot[0] ^= 0xff
+	if proof.Verify(sha256.New(), refs, wrongRoot) {
+		t.Error("proof verified against a tampered root")
+	}
+
+	wrongRefs := [][]byte{all[1], []byte{99}, all[7]}
+	if proof.Verify(sha256.New(), wrongRefs, root) {
+		t.Error("proof verified against a tampered ref")
+	}
+
+	if proof.Verify(sha256.New(), refs[:2], root) {
+		t.Error("proof verified against too few refs")
+	}
+}
+
+func TestMultiProofZeroLeaves(t *testing.T) {
+	mtree := NewMultiProofTree(sha256.New(), nil)
+	proof := mtree.MultiProof()
+
+	if len(proof.Indices) != 0 {
+		t.Errorf("Indices = %v, want none", proof.Indices)
+	}
+	if len(proof.Helpers) != 0 {
+		t.Errorf("Helpers = %v, want none", proof.Helpers)
+	}
+}
+
+func evenIndices(n int) []int {
+	var out []int
+	for i := 0; i < n; i += 2 {
+		out = append(out, i)
+	}
+	return out
+}
+
+func allIndices(n int) []int {
+	var out []int
+	for i := 0; i < n; i++ {
+		out = append(out, i)
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestNonMembershipProof(t *testing.T) {
+	var f Frontier
+	for _, s := range []string{"ab", "abc", "ba"} {
+		f.Exclude([]byte(s))
+	}
+	root := f.MerkleTree(sha256.New()).Root()
+
+	for _, s := range []string{"c", "ac", "bac", "abd", "abcx"} {
+		prefix, proof, ok := f.NonMembershipProof(sha256.New(), []byte(s))
+		if !ok {
+			t.Errorf("%q: NonMembershipProof reported no excluded prefix", s)
+			continue
+		}
+		if !bytes.HasPrefix([]byte(s), prefix) {
+			t.Errorf("%q: returned prefix %q is not a prefix of s", s, prefix)
+		}
+		if !VerifyNonMembership(sha256.New(), root, []byte(s), prefix, proof) {
+			t.Errorf("%q: valid non-membership proof (prefix %q) failed to verify", s, prefix)
+		}
+	}
+}
+
+func TestNonMembershipProofRejectsMembers(t *testing.T) {
+	var f Frontier
+	f.Exclude([]byte("ab"))
+
+	if _, _, ok := f.NonMembershipProof(sha256.New(), []byte("a")); ok {
+		t.Error("NonMembershipProof reported an excluded prefix for a string that was added to the set")
+	}
+}
+
+func TestNonMembershipProofRejectsTampering(t *testing.T) {
+	var f Frontier
+	f.Exclude([]byte("ab"))
+	root := f.MerkleTree(sha256.New()).Root()
+
+	s := []byte("ac")
+	prefix, proof, ok := f.NonMembershipProof(sha256.New(), s)
+	if !ok {
+		t.Fatal("NonMembershipProof reported no excluded prefix")
+	}
+	if !VerifyNonMembership(sha256.New(), root, s, prefix, proof) {
+		t.Fatal("valid non-membership proof failed to verify")
+	}
+
+	if VerifyNonMembership(sha256.New(), root, []byte("zz"), prefix, proof) {
+		t.Error("proof verified against a string the prefix doesn't match")
+	}
+
+	wrongRoot := append([]byte{}, root...)
+	wrongRoot[0] ^= 0xff
+	if VerifyNonMembership(sha256.New(), wrongRoot, s, prefix, proof) {
+		t.Error("proof verified against a tampered root")
+	}
+}
+
+func TestNonMembershipProofExactMatch(t *testing.T) {
+	var f Frontier
+	f.Exclude([]byte("ab"))
+	root := f.MerkleTree(sha256.New()).Root()
+
+	s := []byte("ab")
+	gotPrefix, checkOK := f.Check(s)
+	if !checkOK || !bytes.Equal(gotPrefix, s) {
+		t.Fatalf("Check(%q) = (%q, %v), want (%q, true)", s, gotPrefix, checkOK, s)
+	}
+
+	prefix, proof, ok := f.NonMembershipProof(sha256.New(), s)
+	if !ok {
+		t.Fatal("NonMembershipProof reported no excluded prefix for a string that was itself Excluded")
+	}
+	if !bytes.HasPrefix(prefix, s) {
+		t.Errorf("returned leaf %q does not extend s=%q", prefix, s)
+	}
+	if !VerifyNonMembership(sha256.New(), root, s, prefix, proof) {
+		t.Error("valid non-membership proof for an Excluded string failed to verify")
+	}
+}
+
+func TestNonMembershipProofEmptyFrontier(t *testing.T) {
+	var f Frontier
+	root := f.MerkleTree(sha256.New()).Root()
+
+	s := []byte("anything")
+	prefix, proof, ok := f.NonMembershipProof(sha256.New(), s)
+	if !ok {
+		t.Fatal("NonMembershipProof reported no excluded prefix in an empty frontier")
+	}
+	if len(prefix) != 0 {
+		t.Errorf("prefix = %q, want empty (an empty frontier excludes every string)", prefix)
+	}
+	if !VerifyNonMembership(sha256.New(), root, s, prefix, proof) {
+		t.Error("valid non-membership proof against an empty frontier failed to verify")
+	}
+}
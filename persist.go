@@ -0,0 +1,226 @@
+package merkle
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+)
+
+// magic identifies the binary encoding produced by HTree.MarshalBinary.
+var magic = [4]byte{'M', 'K', 'H', '1'}
+
+const marshalVersion = 1
+
+// MarshalBinary encodes h's incremental state: the right spine of pending
+// subtree roots, and, if h was created with NewProofHTree, the reference
+// hash and in-progress proof. The result can later be passed to
+// UnmarshalHTree, along with a fresh hasher of the same kind, to resume
+// adding leaves where h left off.
+//
+// It is an error to marshal a tree created with newConsistencyHTree or
+// newMultiProofHTree, since such a tree's state includes every leaf
+// added to it rather than just the spine; or a tree created with
+// newFixedDepthHTree, since such a tree stores its leaves sparsely by
+// index rather than in the spine; or a tree on which Root or Proof has
+// already been called.
+func (h *HTree) MarshalBinary() ([]byte, error) {
+	if h.consistency != nil {
+		return nil, fmt.Errorf("merkle: cannot marshal a consistency tree")
+	}
+	if h.multiproof != nil {
+		return nil, fmt.Errorf("merkle: cannot marshal a multi-proof tree")
+	}
+	if h.fixedDepth != nil {
+		return nil, fmt.Errorf("merkle: cannot marshal a fixed-depth tree")
+	}
+	if h.root != nil {
+		return nil, fmt.Errorf("merkle: cannot marshal a finished tree")
+	}
+
+	digestSize := h.hasher.Size()
+	if digestSize > 255 {
+		return nil, fmt.Errorf("merkle: hasher digest size %d too large to marshal", digestSize)
+	}
+
+	buf := append([]byte{}, magic[:]...)
+	buf = append(buf, marshalVersion, byte(digestSize))
+	buf = appendUint32(buf, uint32(len(h.hashes)))
+	if h.ref == nil {
+		buf = append(buf, 0)
+	} else {
+		buf = append(buf, 1)
+	}
+
+	for _, hh := range h.hashes {
+		if hh == nil {
+			buf = append(buf, 0)
+			continue
+		}
+		if len(hh) != digestSize {
+			return nil, fmt.Errorf("merkle: spine hash has length %d, want %d", len(hh), digestSize)
+		}
+		buf = append(buf, 1)
+		buf = append(buf, hh...)
+	}
+
+	if h.ref == nil {
+		return buf, nil
+	}
+	buf = appendUint32(buf, uint32(len(*h.ref)))
+	buf = append(buf, *h.ref...)
+	buf = marshalProof(buf, *h.proof)
+
+	return buf, nil
+}
+
+// UnmarshalHTree decodes data, produced by HTree.MarshalBinary, into an
+// HTree that can resume receiving leaves via Add. The supplied hasher
+// must be a fresh instance of the same hash function used to produce
+// data.
+func UnmarshalHTree(hasher hash.Hash, data []byte) (*HTree, error) {
+	if len(data) < 6 || [4]byte{data[0], data[1], data[2], data[3]} != magic {
+		return nil, fmt.Errorf("merkle: bad magic bytes")
+	}
+	data = data[4:]
+
+	version := data[0]
+	if version != marshalVersion {
+		return nil, fmt.Errorf("merkle: unsupported encoding version %d", version)
+	}
+	data = data[1:]
+
+	digestSize := int(data[0])
+	if digestSize != hasher.Size() {
+		return nil, fmt.Errorf("merkle: encoded digest size %d does not match hasher digest size %d", digestSize, hasher.Size())
+	}
+	data = data[1:]
+
+	spineLen, data, err := readUint32(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 1 {
+		return nil, fmt.Errorf("merkle: truncated ref flag")
+	}
+	hasRef := data[0]
+	data = data[1:]
+
+	h := &HTree{hasher: hasher}
+
+	for i := uint32(0); i < spineLen; i++ {
+		if len(data) < 1 {
+			return nil, fmt.Errorf("merkle: truncated spine")
+		}
+		present := data[0]
+		data = data[1:]
+		if present == 0 {
+			h.hashes = append(h.hashes, nil)
+			continue
+		}
+		if len(data) < digestSize {
+			return nil, fmt.Errorf("merkle: truncated spine hash")
+		}
+		hh := make([]byte, digestSize)
+		copy(hh, data[:digestSize])
+		data = data[digestSize:]
+		h.hashes = append(h.hashes, hh)
+	}
+
+	if hasRef == 0 {
+		return h, nil
+	}
+
+	refLen, data, err := readUint32(data)
+	if err != nil {
+		return nil, err
+	}
+	if uint32(len(data)) < refLen {
+		return nil, fmt.Errorf("merkle: truncated ref")
+	}
+	ref := make([]byte, refLen)
+	copy(ref, data[:refLen])
+	data = data[refLen:]
+	h.ref = &ref
+
+	proof, _, err := unmarshalProof(data)
+	if err != nil {
+		return nil, err
+	}
+	h.proof = &proof
+
+	return h, nil
+}
+
+// marshalProof appends the binary encoding of p to buf: a
+// needsLeafHashing flag byte, followed by a count-prefixed list of
+// steps, each a length-prefixed hash and a Left flag byte.
+func marshalProof(buf []byte, p Proof) []byte {
+	if p.needsLeafHashing {
+		buf = append(buf, 1)
+	} else {
+		buf = append(buf, 0)
+	}
+	buf = appendUint32(buf, uint32(len(p.Steps)))
+	for _, step := range p.Steps {
+		buf = appendUint32(buf, uint32(len(step.H)))
+		buf = append(buf, step.H...)
+		if step.Left {
+			buf = append(buf, 1)
+		} else {
+			buf = append(buf, 0)
+		}
+	}
+	return buf
+}
+
+func unmarshalProof(data []byte) (Proof, []byte, error) {
+	if len(data) < 1 {
+		return Proof{}, nil, fmt.Errorf("merkle: truncated proof")
+	}
+	needsLeafHashing := data[0] != 0
+	data = data[1:]
+
+	count, data, err := readUint32(data)
+	if err != nil {
+		return Proof{}, nil, err
+	}
+
+	p := Proof{needsLeafHashing: needsLeafHashing}
+	for i := uint32(0); i < count; i++ {
+		hLen, rest, err := readUint32(data)
+		if err != nil {
+			return Proof{}, nil, err
+		}
+		data = rest
+		if uint32(len(data)) < hLen {
+			return Proof{}, nil, fmt.Errorf("merkle: truncated proof step")
+		}
+		h := make([]byte, hLen)
+		copy(h, data[:hLen])
+		data = data[hLen:]
+
+		if len(data) < 1 {
+			return Proof{}, nil, fmt.Errorf("merkle: truncated proof step")
+		}
+		left := data[0] != 0
+		data = data[1:]
+
+		p.Steps = append(p.Steps, ProofStep{H: h, Left: left})
+	}
+
+	return p, data, nil
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	var tmp [4]byte
+	binary.BigEndian.PutUint32(tmp[:], v)
+	return append(buf, tmp[:]...)
+}
+
+func readUint32(data []byte) (uint32, []byte, error) {
+	if len(data) < 4 {
+		return 0, nil, fmt.Errorf("merkle: truncated length prefix")
+	}
+	return binary.BigEndian.Uint32(data[:4]), data[4:], nil
+}
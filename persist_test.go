@@ -0,0 +1,126 @@
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestMarshalUnmarshalHTree(t *testing.T) {
+	var leaves [][]byte
+	for i := 0; i < 11; i++ {
+		leaves = append(leaves, LeafHash(sha256.New(), nil, []byte{byte(i)}))
+	}
+
+	// Add mutates the space it's given, so each tree below needs its own
+	// copy of the leaf hashes.
+	cloneLeaves := func() [][]byte {
+		out := make([][]byte, len(leaves))
+		for i, l := range leaves {
+			out[i] = append([]byte{}, l...)
+		}
+		return out
+	}
+
+	want := NewHTree(sha256.New())
+	for _, l := range cloneLeaves() {
+		want.Add(l)
+	}
+	wantRoot := want.Root()
+
+	for split := 0; split <= len(leaves); split++ {
+		ls := cloneLeaves()
+
+		h := NewHTree(sha256.New())
+		for _, l := range ls[:split] {
+			h.Add(l)
+		}
+
+		data, err := h.MarshalBinary()
+		if err != nil {
+			t.Fatalf("split %d: MarshalBinary: %v", split, err)
+		}
+
+		restored, err := UnmarshalHTree(sha256.New(), data)
+		if err != nil {
+			t.Fatalf("split %d: UnmarshalHTree: %v", split, err)
+		}
+		for _, l := range ls[split:] {
+			restored.Add(l)
+		}
+
+		got := restored.Root()
+		if !bytes.Equal(got, wantRoot) {
+			t.Errorf("split %d: got root %x, want %x", split, got, wantRoot)
+		}
+	}
+}
+
+func TestMarshalUnmarshalProofHTree(t *testing.T) {
+	var leaves [][]byte
+	for i := 0; i < 7; i++ {
+		leaves = append(leaves, LeafHash(sha256.New(), nil, []byte{byte(i)}))
+	}
+	ref := append([]byte{}, leaves[3]...)
+
+	h := NewProofHTree(sha256.New(), ref)
+	for _, l := range leaves[:4] {
+		h.Add(l)
+	}
+
+	data, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := UnmarshalHTree(sha256.New(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range leaves[4:] {
+		restored.Add(l)
+	}
+
+	root := restored.Root()
+	proof := restored.Proof()
+	got := proof.Hash(sha256.New(), ref)
+	if !bytes.Equal(got, root) {
+		t.Errorf("proof hash %x does not match root %x", got, root)
+	}
+}
+
+func TestMarshalFinishedHTreeErrors(t *testing.T) {
+	h := NewHTree(sha256.New())
+	h.Add(LeafHash(sha256.New(), nil, []byte{1}))
+	h.Root()
+
+	if _, err := h.MarshalBinary(); err == nil {
+		t.Error("expected an error marshaling a finished tree")
+	}
+}
+
+func TestMarshalConsistencyHTreeErrors(t *testing.T) {
+	h := newConsistencyHTree(sha256.New(), 1)
+	h.Add(LeafHash(sha256.New(), nil, []byte{1}))
+
+	if _, err := h.MarshalBinary(); err == nil {
+		t.Error("expected an error marshaling a consistency tree")
+	}
+}
+
+func TestMarshalMultiProofHTreeErrors(t *testing.T) {
+	h := newMultiProofHTree(sha256.New(), nil)
+	h.Add(LeafHash(sha256.New(), nil, []byte{1}))
+
+	if _, err := h.MarshalBinary(); err == nil {
+		t.Error("expected an error marshaling a multi-proof tree")
+	}
+}
+
+func TestMarshalFixedDepthHTreeErrors(t *testing.T) {
+	h := newFixedDepthHTree(sha256.New(), 2, nil)
+	h.AddAt(0, LeafHash(sha256.New(), nil, []byte{1}))
+
+	if _, err := h.MarshalBinary(); err == nil {
+		t.Error("expected an error marshaling a fixed-depth tree")
+	}
+}